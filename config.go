@@ -0,0 +1,50 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dragonboat
+
+import "github.com/lni/dragonboat/internal/settings"
+
+// NodeHostConfig is the configuration used to configure a NodeHost
+// instance.
+type NodeHostConfig struct {
+	// CompressionType is the default codec used to compress snapshot chunks
+	// and large proposal payloads before they are put on the wire. It can be
+	// overridden per cluster via Config.CompressionType.
+	CompressionType settings.CompressionType
+	// SoftSettingsFiles, when non-empty, are merged in order and applied as
+	// described by settings.WatchSoftSettings, letting soft settings such as
+	// RaftTickDurationMillisecond or LogLevel be tuned without restarting
+	// the process.
+	SoftSettingsFiles []string
+}
+
+// Config is the configuration used to configure a single raft cluster.
+type Config struct {
+	ClusterID uint64
+	NodeID    uint64
+	// CompressionType, when set, overrides the NodeHostConfig level default
+	// for this cluster only.
+	CompressionType *settings.CompressionType
+}
+
+// GetCompressionType returns the effective CompressionType for this
+// cluster, falling back to the NodeHostConfig default when the cluster did
+// not set its own override.
+func (c *Config) GetCompressionType(nhc NodeHostConfig) settings.CompressionType {
+	if c.CompressionType != nil {
+		return *c.CompressionType
+	}
+	return nhc.CompressionType
+}
@@ -0,0 +1,77 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventstream
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeDecodeHeaderFrame(t *testing.T) {
+	encoded := EncodeHeader(defaultHeader())
+	frame, n, err := DecodeFrame(encoded)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if n != len(encoded) {
+		t.Fatalf("expected to consume %d bytes, consumed %d", len(encoded), n)
+	}
+	if frame.Header == nil || frame.Header.SchemaVersion != schemaVersion {
+		t.Fatalf("unexpected header frame, %+v", frame.Header)
+	}
+}
+
+func TestEncodeDecodeRecordFrame(t *testing.T) {
+	r := RecordFrame{ClusterID: 1, Index: 2, Term: 3, Payload: []byte("hello")}
+	encoded := EncodeRecord(r)
+	frame, n, err := DecodeFrame(encoded)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if n != len(encoded) {
+		t.Fatalf("expected to consume %d bytes, consumed %d", len(encoded), n)
+	}
+	if frame.Record == nil || !bytes.Equal(frame.Record.Payload, r.Payload) ||
+		frame.Record.ClusterID != r.ClusterID || frame.Record.Index != r.Index ||
+		frame.Record.Term != r.Term {
+		t.Fatalf("decoded record frame mismatch, got %+v", frame.Record)
+	}
+}
+
+func TestDecodeRecordFrameDetectsCorruption(t *testing.T) {
+	encoded := EncodeRecord(RecordFrame{ClusterID: 1, Index: 2, Term: 3, Payload: []byte("hello")})
+	encoded[len(encoded)-1] ^= 0xff
+	if _, _, err := DecodeFrame(encoded); err == nil {
+		t.Fatal("expected a CRC mismatch error for a corrupted frame")
+	}
+}
+
+func TestEncodeHeartbeatFrame(t *testing.T) {
+	encoded := EncodeHeartbeat()
+	frame, n, err := DecodeFrame(encoded)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if n != len(encoded) || !frame.Heartbeat {
+		t.Fatalf("expected a heartbeat frame, got %+v", frame)
+	}
+}
+
+func TestDecodeFrameRejectsTruncatedInput(t *testing.T) {
+	encoded := EncodeRecord(RecordFrame{ClusterID: 1, Index: 2, Term: 3, Payload: []byte("hello")})
+	if _, _, err := DecodeFrame(encoded[:len(encoded)-1]); err == nil {
+		t.Fatal("expected an error for truncated input")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventstream
+
+import "errors"
+
+// ErrCompacted is returned by LogReader.EntriesFrom when the requested
+// starting index has already been compacted away from the log, the caller
+// should fall back to a snapshot before resuming the live tail.
+var ErrCompacted = errors.New("requested index has been compacted")
+
+// LogReader is the subset of LogDB needed to serve a subscriber's backlog.
+type LogReader interface {
+	// EntriesFrom returns all currently available entries for clusterID at
+	// or after fromIndex. It returns ErrCompacted when fromIndex is no
+	// longer present in the log.
+	EntriesFrom(clusterID uint64, fromIndex uint64) ([]RecordFrame, error)
+}
+
+// SnapshotProvider is the subset of the snapshot subsystem needed to resume
+// a subscriber whose requested index has been compacted away.
+type SnapshotProvider interface {
+	// LatestSnapshot returns the most recent snapshot's index for
+	// clusterID, streaming from there onwards.
+	LatestSnapshot(clusterID uint64) (index uint64, err error)
+}
+
+// Sink receives the raw, already framed bytes to be written to a
+// subscriber's connection, e.g. a gRPC stream or a plain net.Conn.
+type Sink interface {
+	Send(frame []byte) error
+}
+
+// Subscribe serves a single subscriber from fromIndex onwards until stop is
+// closed or sending a frame fails. The header frame is always sent first,
+// then either the backlog still held in LogReader or, if fromIndex has been
+// compacted away, the backlog starting right after the index of the
+// cluster's latest snapshot. The snapshot's own content is not sent, only
+// its index is used. Once the backlog has been drained, Subscribe
+// blocks serving newly committed entries as they arrive on tail, sending a
+// heartbeat frame every heartbeatEvery() ticks received on ticks whenever
+// the stream would otherwise sit idle, so a subscriber can always tell the
+// connection apart from a dead one.
+func Subscribe(clusterID uint64, fromIndex uint64, log LogReader,
+	snap SnapshotProvider, tail <-chan RecordFrame, ticks <-chan struct{},
+	stop <-chan struct{}, sink Sink) error {
+	if err := sink.Send(EncodeHeader(defaultHeader())); err != nil {
+		return err
+	}
+	records, err := log.EntriesFrom(clusterID, fromIndex)
+	if err == ErrCompacted {
+		snapshotIndex, serr := snap.LatestSnapshot(clusterID)
+		if serr != nil {
+			return serr
+		}
+		records, err = log.EntriesFrom(clusterID, snapshotIndex+1)
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := sink.Send(EncodeRecord(r)); err != nil {
+			return err
+		}
+	}
+	return tailAndHeartbeat(clusterID, tail, ticks, stop, sink)
+}
+
+// tailAndHeartbeat is the live continuation loop run once the backlog has
+// been fully drained: it forwards every newly committed entry received on
+// tail as a record frame, and sends a heartbeat frame once heartbeatEvery()
+// ticks have elapsed with nothing sent, so idle streams do not look dead.
+func tailAndHeartbeat(clusterID uint64, tail <-chan RecordFrame,
+	ticks <-chan struct{}, stop <-chan struct{}, sink Sink) error {
+	every := heartbeatEvery()
+	var idleTicks uint64
+	for {
+		select {
+		case <-stop:
+			return nil
+		case r, ok := <-tail:
+			if !ok {
+				return nil
+			}
+			if r.ClusterID != clusterID {
+				continue
+			}
+			idleTicks = 0
+			if err := sink.Send(EncodeRecord(r)); err != nil {
+				return err
+			}
+		case <-ticks:
+			idleTicks++
+			if every > 0 && idleTicks >= every {
+				if err := Heartbeat(sink); err != nil {
+					return err
+				}
+				idleTicks = 0
+			}
+		}
+	}
+}
+
+// Heartbeat sends a single heartbeat/continuation frame to sink, it is
+// called by tailAndHeartbeat every heartbeatEvery() ticks while a
+// subscriber's tail is otherwise idle.
+func Heartbeat(sink Sink) error {
+	return sink.Send(EncodeHeartbeat())
+}
@@ -0,0 +1,166 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventstream
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLog struct {
+	compactedBefore uint64
+	records         []RecordFrame
+}
+
+func (l *fakeLog) EntriesFrom(clusterID uint64, fromIndex uint64) ([]RecordFrame, error) {
+	if fromIndex < l.compactedBefore {
+		return nil, ErrCompacted
+	}
+	var result []RecordFrame
+	for _, r := range l.records {
+		if r.ClusterID == clusterID && r.Index >= fromIndex {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+type fakeSnapshot struct {
+	index uint64
+}
+
+func (s *fakeSnapshot) LatestSnapshot(clusterID uint64) (uint64, error) {
+	return s.index, nil
+}
+
+type recordingSink struct {
+	mu     sync.Mutex
+	frames [][]byte
+}
+
+func (s *recordingSink) Send(frame []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frames = append(s.frames, frame)
+	return nil
+}
+
+func (s *recordingSink) frameCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.frames)
+}
+
+// closedStop returns an already-closed stop channel, so Subscribe returns
+// as soon as it finishes draining the backlog instead of blocking forever
+// in the live tail loop.
+func closedStop() chan struct{} {
+	stop := make(chan struct{})
+	close(stop)
+	return stop
+}
+
+func TestSubscribeServesFromLogWhenNotCompacted(t *testing.T) {
+	log := &fakeLog{records: []RecordFrame{
+		{ClusterID: 1, Index: 5, Payload: []byte("a")},
+		{ClusterID: 1, Index: 6, Payload: []byte("b")},
+	}}
+	sink := &recordingSink{}
+	if err := Subscribe(1, 5, log, &fakeSnapshot{}, nil, nil, closedStop(), sink); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	// one header frame plus two record frames.
+	if len(sink.frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(sink.frames))
+	}
+	frame, _, err := DecodeFrame(sink.frames[0])
+	if err != nil || frame.Header == nil {
+		t.Fatalf("expected the first frame to be a header frame, err=%v frame=%+v", err, frame)
+	}
+}
+
+func TestSubscribeFallsBackToSnapshotWhenCompacted(t *testing.T) {
+	log := &fakeLog{compactedBefore: 10, records: []RecordFrame{
+		{ClusterID: 1, Index: 11, Payload: []byte("a")},
+	}}
+	sink := &recordingSink{}
+	if err := Subscribe(1, 1, log, &fakeSnapshot{index: 10}, nil, nil, closedStop(), sink); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if len(sink.frames) != 2 {
+		t.Fatalf("expected a header frame plus the resumed record, got %d frames", len(sink.frames))
+	}
+	frame, _, err := DecodeFrame(sink.frames[1])
+	if err != nil || frame.Record == nil || frame.Record.Index != 11 {
+		t.Fatalf("expected the resumed tail to start at index 11, got %+v err=%v", frame, err)
+	}
+}
+
+func TestSubscribeForwardsNewlyTailedEntries(t *testing.T) {
+	log := &fakeLog{}
+	tail := make(chan RecordFrame, 1)
+	stop := make(chan struct{})
+	sink := &recordingSink{}
+	done := make(chan error, 1)
+	go func() {
+		done <- Subscribe(1, 1, log, &fakeSnapshot{}, tail, nil, stop, sink)
+	}()
+	tail <- RecordFrame{ClusterID: 1, Index: 9, Payload: []byte("live")}
+	waitForFrameCount(t, sink, 2)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	frame, _, err := DecodeFrame(sink.frames[1])
+	if err != nil || frame.Record == nil || frame.Record.Index != 9 {
+		t.Fatalf("expected the tailed record to be forwarded, got %+v err=%v", frame, err)
+	}
+}
+
+func TestSubscribeSendsHeartbeatWhenIdle(t *testing.T) {
+	log := &fakeLog{}
+	ticks := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	sink := &recordingSink{}
+	done := make(chan error, 1)
+	go func() {
+		done <- Subscribe(1, 1, log, &fakeSnapshot{}, nil, ticks, stop, sink)
+	}()
+	for i := uint64(0); i < heartbeatEvery(); i++ {
+		ticks <- struct{}{}
+	}
+	waitForFrameCount(t, sink, 2)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	frame, _, err := DecodeFrame(sink.frames[1])
+	if err != nil || !frame.Heartbeat {
+		t.Fatalf("expected an idle heartbeat frame, got %+v err=%v", frame, err)
+	}
+}
+
+func waitForFrameCount(t *testing.T, sink *recordingSink, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if sink.frameCount() >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d frames, got %d", n, sink.frameCount())
+}
@@ -0,0 +1,172 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"errors"
+
+	"github.com/lni/dragonboat/internal/settings"
+	"github.com/lni/dragonboat/logger"
+)
+
+var plog = logger.GetLogger("raft")
+
+// ErrUncommittedSizeLimitReached is returned by appendEntry when accepting
+// the supplied entries would push the leader's in-memory uncommitted log
+// size past settings.Hard.MaxUncommittedEntriesSize. The caller should
+// surface this as backpressure to the proposer rather than buffering the
+// entries.
+var ErrUncommittedSizeLimitReached = errors.New("uncommitted entries size limit reached")
+
+// Entry is a single raft log entry.
+type Entry struct {
+	Index uint64
+	Term  uint64
+	Data  []byte
+}
+
+// entryLog manages the in-memory raft log kept by a node, including the
+// commit/apply indexes and the aggregate size of entries appended past the
+// commit index but not yet applied.
+type entryLog struct {
+	entries []Entry
+	// committed is the index up to which entries have been committed.
+	committed uint64
+	// applied is the index up to which entries have been applied to the RSM.
+	applied uint64
+	// uncommittedSize is the aggregate payload size, in bytes, of the
+	// entries appended by the leader past committed but not yet applied. It
+	// is only meaningful while the node is the leader, it is reset to 0 as
+	// soon as the node steps down.
+	uncommittedSize uint64
+	// maxUncommittedSize is the configured upper bound for uncommittedSize,
+	// it defaults to settings.Hard.MaxUncommittedEntriesSize and is kept as
+	// a field so tests can exercise the limit without touching the global.
+	maxUncommittedSize uint64
+	// maxCommittedSizePerReady is the configured upper bound enforced by
+	// nextEnts, it defaults to settings.MaxCommittedSizePerReady and is kept
+	// as a field so tests can exercise the cap without touching the global.
+	maxCommittedSizePerReady uint64
+}
+
+func newEntryLog() *entryLog {
+	return &entryLog{
+		maxUncommittedSize:       settings.Hard.MaxUncommittedEntriesSize,
+		maxCommittedSizePerReady: settings.MaxCommittedSizePerReady,
+	}
+}
+
+// appendEntry appends the given entries to the leader's log, rejecting the
+// whole batch with ErrUncommittedSizeLimitReached when doing so would push
+// uncommittedSize past maxUncommittedSize. A batch made up entirely of
+// no-op entries, e.g. the empty entry a new leader appends on election, is
+// always allowed through regardless of the limit so elections can never be
+// blocked by the very protection meant to keep the leader healthy.
+func (l *entryLog) appendEntry(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var newSize uint64
+	hasPayload := false
+	for _, e := range entries {
+		if len(e.Data) > 0 {
+			hasPayload = true
+		}
+		newSize += uint64(len(e.Data))
+	}
+	if hasPayload && l.maxUncommittedSize > 0 &&
+		l.uncommittedSize+newSize > l.maxUncommittedSize {
+		plog.Warningf("rejecting %d new entries, uncommitted size %d + %d > limit %d",
+			len(entries), l.uncommittedSize, newSize, l.maxUncommittedSize)
+		return ErrUncommittedSizeLimitReached
+	}
+	l.entries = append(l.entries, entries...)
+	l.uncommittedSize += newSize
+	return nil
+}
+
+// commitTo moves the commit index forward to index.
+func (l *entryLog) commitTo(index uint64) {
+	if index > l.committed {
+		l.committed = index
+	}
+}
+
+// advance is invoked once appliedEntries have been applied to the state
+// machine, it releases their share of uncommittedSize.
+func (l *entryLog) advance(appliedEntries []Entry) {
+	for _, e := range appliedEntries {
+		size := uint64(len(e.Data))
+		if size <= l.uncommittedSize {
+			l.uncommittedSize -= size
+		} else {
+			l.uncommittedSize = 0
+		}
+		if e.Index > l.applied {
+			l.applied = e.Index
+		}
+	}
+}
+
+// resetUncommittedSize zeroes the uncommitted size counter, it is called
+// whenever the node steps down from leader so the next leader term starts
+// from a clean slate instead of inheriting stale accounting.
+func (l *entryLog) resetUncommittedSize() {
+	l.uncommittedSize = 0
+}
+
+// nextEnts returns the entries between applied and committed that are to be
+// included in the next Ready struct handed to the step engine, capped to
+// settings.MaxCommittedSizePerReady in aggregate size. This is independent
+// from the MaxMessageSize based cap applied when replicating entries to
+// followers, it only bounds how much work the local RSM worker is asked to
+// apply in one go.
+func (l *entryLog) nextEnts() []Entry {
+	if l.applied >= l.committed {
+		return nil
+	}
+	ents := l.slice(l.applied+1, l.committed)
+	return limitSize(ents, l.maxCommittedSizePerReady)
+}
+
+// slice returns the entries with index in [lo, hi].
+func (l *entryLog) slice(lo uint64, hi uint64) []Entry {
+	var result []Entry
+	for _, e := range l.entries {
+		if e.Index >= lo && e.Index <= hi {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// limitSize returns the longest prefix of ents whose aggregate Data size
+// does not exceed maxSize, always including at least the first entry even
+// when that single entry's size already exceeds maxSize on its own -
+// otherwise a single oversized entry would stall the apply loop forever.
+func limitSize(ents []Entry, maxSize uint64) []Entry {
+	if len(ents) == 0 {
+		return ents
+	}
+	size := uint64(len(ents[0].Data))
+	limit := 1
+	for ; limit < len(ents); limit++ {
+		size += uint64(len(ents[limit].Data))
+		if size > maxSize {
+			break
+		}
+	}
+	return ents[:limit]
+}
@@ -0,0 +1,73 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import "testing"
+
+func TestAppendEntryRejectsBatchExceedingUncommittedSizeLimit(t *testing.T) {
+	l := &entryLog{maxUncommittedSize: 10}
+	if err := l.appendEntry([]Entry{{Index: 1, Data: make([]byte, 6)}}); err != nil {
+		t.Fatalf("unexpected error for first append, %v", err)
+	}
+	if err := l.appendEntry([]Entry{{Index: 2, Data: make([]byte, 5)}}); err != ErrUncommittedSizeLimitReached {
+		t.Fatalf("expected ErrUncommittedSizeLimitReached, got %v", err)
+	}
+	if l.uncommittedSize != 6 {
+		t.Fatalf("uncommittedSize unexpectedly changed by the rejected append, got %d", l.uncommittedSize)
+	}
+}
+
+func TestAppendEntryAlwaysAllowsNoopEntries(t *testing.T) {
+	l := &entryLog{maxUncommittedSize: 10, uncommittedSize: 10}
+	if err := l.appendEntry([]Entry{{Index: 1}}); err != nil {
+		t.Fatalf("no-op entry should always be allowed, got %v", err)
+	}
+	if err := l.appendEntry(nil); err != nil {
+		t.Fatalf("empty batch should always be allowed, got %v", err)
+	}
+}
+
+func TestAdvanceReleasesUncommittedSize(t *testing.T) {
+	l := &entryLog{maxUncommittedSize: 100, uncommittedSize: 10}
+	l.advance([]Entry{{Index: 1, Data: make([]byte, 4)}})
+	if l.uncommittedSize != 6 {
+		t.Fatalf("expected uncommittedSize 6, got %d", l.uncommittedSize)
+	}
+	if l.applied != 1 {
+		t.Fatalf("expected applied index 1, got %d", l.applied)
+	}
+}
+
+func TestResetUncommittedSizeOnStepDown(t *testing.T) {
+	r := newRaft(1, 1)
+	r.becomeLeader(1)
+	if err := r.proposeEntries([]Entry{{Index: 1, Data: make([]byte, 4)}}); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if r.log.uncommittedSize == 0 {
+		t.Fatalf("expected non-zero uncommittedSize after proposing entries")
+	}
+	r.becomeFollower(2)
+	if r.log.uncommittedSize != 0 {
+		t.Fatalf("expected uncommittedSize to be reset on step down, got %d", r.log.uncommittedSize)
+	}
+}
+
+func TestProposeEntriesRejectedWhenNotLeader(t *testing.T) {
+	r := newRaft(1, 1)
+	if err := r.proposeEntries([]Entry{{Index: 1}}); err != ErrNotLeader {
+		t.Fatalf("expected ErrNotLeader, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import "testing"
+
+func entries(sizes ...int) []Entry {
+	result := make([]Entry, 0, len(sizes))
+	for i, size := range sizes {
+		result = append(result, Entry{Index: uint64(i + 1), Data: make([]byte, size)})
+	}
+	return result
+}
+
+func TestLimitSizeCapsByAggregateSize(t *testing.T) {
+	ents := entries(4, 4, 4, 4)
+	got := limitSize(ents, 10)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries within the 10 byte budget, got %d", len(got))
+	}
+}
+
+func TestLimitSizeAlwaysReturnsOversizedFirstEntry(t *testing.T) {
+	ents := entries(100, 4)
+	got := limitSize(ents, 10)
+	if len(got) != 1 {
+		t.Fatalf("expected the single oversized entry to still be returned, got %d entries", len(got))
+	}
+}
+
+func TestNextEntsAppliesMaxCommittedSizePerReady(t *testing.T) {
+	l := &entryLog{entries: entries(4, 4, 4, 4), maxCommittedSizePerReady: 8}
+	l.commitTo(4)
+	got := l.nextEnts()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries within the 8 byte budget, got %d", len(got))
+	}
+}
+
+func TestNextEntsReturnsNilWhenNothingToApply(t *testing.T) {
+	l := &entryLog{entries: entries(4, 4), maxCommittedSizePerReady: 8}
+	if got := l.nextEnts(); got != nil {
+		t.Fatalf("expected nil when applied >= committed, got %v", got)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import "errors"
+
+// State is the role a raft node is currently playing.
+type State uint64
+
+const (
+	follower State = iota
+	candidate
+	leader
+)
+
+// ErrNotLeader is returned when a proposal is made to a node that is not
+// the current leader of its cluster.
+var ErrNotLeader = errors.New("not the leader")
+
+// Status is a snapshot of a raft node's internal state, it is exposed so
+// the rest of the system, e.g. NodeHost metrics, can report on it without
+// reaching into raft internals directly.
+type Status struct {
+	ClusterID uint64
+	NodeID    uint64
+	Term      uint64
+	State     State
+	// UncommittedLogSize is the current value of the leader's uncommitted
+	// entries size counter, it stays 0 on followers and candidates.
+	UncommittedLogSize uint64
+}
+
+// raft is a single raft node's core state machine. It currently only
+// implements the bits of state needed to track and enforce
+// MaxUncommittedEntriesSize, the rest of the consensus protocol (election,
+// vote counting, replication to followers, etc) lives elsewhere and is not
+// part of this change.
+type raft struct {
+	clusterID uint64
+	nodeID    uint64
+	term      uint64
+	state     State
+	log       *entryLog
+}
+
+func newRaft(clusterID uint64, nodeID uint64) *raft {
+	return &raft{
+		clusterID: clusterID,
+		nodeID:    nodeID,
+		state:     follower,
+		log:       newEntryLog(),
+	}
+}
+
+// becomeLeader transitions the node to the leader role for the given term.
+func (r *raft) becomeLeader(term uint64) {
+	r.term = term
+	r.state = leader
+}
+
+// becomeFollower transitions the node to the follower role, stepping down
+// as leader if it currently is one. Stepping down resets the uncommitted
+// log size counter so the next leader term does not inherit stale
+// accounting from this one.
+func (r *raft) becomeFollower(term uint64) {
+	r.term = term
+	r.state = follower
+	r.log.resetUncommittedSize()
+}
+
+// proposeEntries is called on the leader to append newly proposed entries
+// to its log, enforcing MaxUncommittedEntriesSize via entryLog.appendEntry.
+func (r *raft) proposeEntries(entries []Entry) error {
+	if r.state != leader {
+		return ErrNotLeader
+	}
+	return r.log.appendEntry(entries)
+}
+
+// applyEntries is called once entries have been applied to the state
+// machine, releasing their share of the uncommitted log size.
+func (r *raft) applyEntries(entries []Entry) {
+	r.log.advance(entries)
+}
+
+// commitEntries moves the commit index forward, making previously proposed
+// entries eligible to be returned by nextCommittedEntries.
+func (r *raft) commitEntries(index uint64) {
+	r.log.commitTo(index)
+}
+
+// nextCommittedEntries returns the batch of committed but not yet applied
+// entries to hand to the step engine in the next Ready, capped by
+// settings.MaxCommittedSizePerReady.
+func (r *raft) nextCommittedEntries() []Entry {
+	return r.log.nextEnts()
+}
+
+func (r *raft) status() Status {
+	uncommitted := uint64(0)
+	if r.state == leader {
+		uncommitted = r.log.uncommittedSize
+	}
+	return Status{
+		ClusterID:          r.clusterID,
+		NodeID:             r.nodeID,
+		Term:               r.term,
+		State:              r.state,
+		UncommittedLogSize: uncommitted,
+	}
+}
+
+// Node is the handle through which packages outside internal/raft drive a
+// single raft instance, e.g. NodeHost.syncPropose. It only exposes the
+// subset of raft needed to propose entries and have
+// MaxUncommittedEntriesSize enforced on them; the rest of raft stays
+// unexported since nothing outside this package needs it yet.
+type Node struct {
+	r *raft
+}
+
+// NewNode creates a Node for the given cluster/node pair, starting out as a
+// follower.
+func NewNode(clusterID uint64, nodeID uint64) *Node {
+	return &Node{r: newRaft(clusterID, nodeID)}
+}
+
+// BecomeLeader transitions the node to the leader role for term.
+func (n *Node) BecomeLeader(term uint64) {
+	n.r.becomeLeader(term)
+}
+
+// BecomeFollower transitions the node to the follower role for term,
+// resetting its uncommitted log size accounting.
+func (n *Node) BecomeFollower(term uint64) {
+	n.r.becomeFollower(term)
+}
+
+// Propose appends entries to the node's log, returning
+// ErrUncommittedSizeLimitReached if doing so would push the leader's
+// uncommitted log size past MaxUncommittedEntriesSize, or ErrNotLeader if
+// the node is not currently the leader.
+func (n *Node) Propose(entries []Entry) error {
+	return n.r.proposeEntries(entries)
+}
+
+// Applied releases the uncommitted log size held by entries now that they
+// have been applied to the state machine.
+func (n *Node) Applied(entries []Entry) {
+	n.r.applyEntries(entries)
+}
+
+// CommitTo moves the node's commit index forward to index.
+func (n *Node) CommitTo(index uint64) {
+	n.r.commitEntries(index)
+}
+
+// NextCommittedEntries returns the batch of committed but not yet applied
+// entries to hand to the step engine next, see raft.nextCommittedEntries.
+func (n *Node) NextCommittedEntries() []Entry {
+	return n.r.nextCommittedEntries()
+}
+
+// Status returns a snapshot of the node's current state.
+func (n *Node) Status() Status {
+	return n.r.status()
+}
@@ -0,0 +1,54 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"time"
+
+	"github.com/lni/dragonboat/internal/settings"
+)
+
+// Ticker drives the raft ticks for the nodes owned by a single step engine
+// worker. Its interval is re-read from settings.GetSoft() on every tick so
+// a SIGHUP driven change to RaftTickDurationMillisecond takes effect on the
+// next tick without restarting the worker.
+type Ticker struct {
+	timer *time.Timer
+}
+
+// NewTicker creates a Ticker armed with the current soft tick interval.
+func NewTicker() *Ticker {
+	return &Ticker{timer: time.NewTimer(tickInterval())}
+}
+
+func tickInterval() time.Duration {
+	return time.Duration(settings.GetSoft().RaftTickDurationMillisecond) * time.Millisecond
+}
+
+// C returns the channel that fires once per tick.
+func (t *Ticker) C() <-chan time.Time {
+	return t.timer.C
+}
+
+// Reset rearms the ticker using the current soft tick interval, it must be
+// called after every fire of C so later SIGHUP driven changes take effect.
+func (t *Ticker) Reset() {
+	t.timer.Reset(tickInterval())
+}
+
+// Stop stops the ticker, releasing its underlying timer.
+func (t *Ticker) Stop() {
+	t.timer.Stop()
+}
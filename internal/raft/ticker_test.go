@@ -0,0 +1,31 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickerFiresAndCanBeReset(t *testing.T) {
+	ticker := NewTicker()
+	defer ticker.Stop()
+	select {
+	case <-ticker.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("ticker did not fire using the default soft tick interval")
+	}
+	ticker.Reset()
+}
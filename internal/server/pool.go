@@ -0,0 +1,98 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server implements the tiered worker pools used to run proposals,
+// ReadIndex based reads and background snapshot/compaction work.
+package server
+
+import (
+	"errors"
+
+	"github.com/lni/dragonboat/internal/settings"
+)
+
+// ErrSystemBusy is returned by Pools.Submit when the target priority tier
+// already has MaxInFlightTasksPerWorker tasks queued per worker. Callers
+// should treat it as backpressure, e.g. return it to the client rather than
+// blocking or queueing the task elsewhere.
+var ErrSystemBusy = errors.New("system busy, too many pending tasks for this priority")
+
+// tier is a fixed size pool of workers all running at the same priority,
+// backed by a single bounded channel so admission can be rejected once the
+// channel is full rather than growing without limit.
+type tier struct {
+	tasks chan func()
+}
+
+func newTier(workerCount uint64, maxInFlightPerWorker uint64) *tier {
+	capacity := workerCount * maxInFlightPerWorker
+	t := &tier{tasks: make(chan func(), capacity)}
+	for i := uint64(0); i < workerCount; i++ {
+		go t.run()
+	}
+	return t
+}
+
+func (t *tier) run() {
+	for f := range t.tasks {
+		f()
+	}
+}
+
+func (t *tier) submit(f func()) error {
+	select {
+	case t.tasks <- f:
+		return nil
+	default:
+		return ErrSystemBusy
+	}
+}
+
+func (t *tier) close() {
+	close(t.tasks)
+}
+
+// Pools is the set of tiered worker pools used to run proposals, reads and
+// background work at their respective priorities.
+type Pools struct {
+	tiers map[settings.Priority]*tier
+}
+
+// NewPools creates the tiered worker pools described by cfg.
+func NewPools(cfg settings.PoolSettings) *Pools {
+	p := &Pools{tiers: make(map[settings.Priority]*tier, len(cfg.WorkerCount))}
+	for prio, workerCount := range cfg.WorkerCount {
+		p.tiers[prio] = newTier(workerCount, cfg.MaxInFlightTasksPerWorker[prio])
+	}
+	return p
+}
+
+// Submit schedules f to run on the pool for the given priority, returning
+// ErrSystemBusy immediately if that tier's queue is already full rather
+// than letting the caller block indefinitely.
+func (p *Pools) Submit(priority settings.Priority, f func()) error {
+	t, ok := p.tiers[priority]
+	if !ok {
+		return ErrSystemBusy
+	}
+	return t.submit(f)
+}
+
+// Close stops accepting new work and shuts down all pool workers. It must
+// only be called once all in-flight Submit callers have returned.
+func (p *Pools) Close() {
+	for _, t := range p.tiers {
+		t.close()
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lni/dragonboat/internal/settings"
+)
+
+func TestPoolsRunSubmittedTasks(t *testing.T) {
+	cfg := settings.PoolSettings{
+		WorkerCount:               map[settings.Priority]uint64{settings.Normal: 2},
+		MaxInFlightTasksPerWorker: map[settings.Priority]uint64{settings.Normal: 4},
+	}
+	p := NewPools(cfg)
+	defer p.Close()
+	done := make(chan struct{}, 1)
+	if err := p.Submit(settings.Normal, func() { done <- struct{}{} }); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task was not executed")
+	}
+}
+
+func TestPoolsRejectWhenTierQueueIsFull(t *testing.T) {
+	cfg := settings.PoolSettings{
+		WorkerCount:               map[settings.Priority]uint64{settings.Low: 1},
+		MaxInFlightTasksPerWorker: map[settings.Priority]uint64{settings.Low: 1},
+	}
+	p := NewPools(cfg)
+	defer p.Close()
+
+	block := make(chan struct{})
+	release := make(chan struct{})
+	// occupy the single worker so the next queued task fills the capacity-1
+	// channel, and the one after that has nowhere to go.
+	if err := p.Submit(settings.Low, func() { close(block); <-release }); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	<-block
+	if err := p.Submit(settings.Low, func() {}); err != nil {
+		t.Fatalf("expected the queued task to be accepted, got %v", err)
+	}
+	if err := p.Submit(settings.Low, func() {}); err != ErrSystemBusy {
+		t.Fatalf("expected ErrSystemBusy, got %v", err)
+	}
+	close(release)
+}
+
+func TestPoolsRejectUnknownPriority(t *testing.T) {
+	p := NewPools(settings.PoolSettings{
+		WorkerCount:               map[settings.Priority]uint64{settings.Normal: 1},
+		MaxInFlightTasksPerWorker: map[settings.Priority]uint64{settings.Normal: 1},
+	})
+	defer p.Close()
+	if err := p.Submit(settings.High, func() {}); err != ErrSystemBusy {
+		t.Fatalf("expected ErrSystemBusy for a tier with no workers, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package settings
+
+// CompressionType is the type of the compression codec optionally applied to
+// snapshot chunks and large proposal payloads before they are put on the
+// wire.
+type CompressionType uint64
+
+const (
+	// NoCompression disables compression, chunks and proposals are sent as
+	// is. This is the default so existing deployments see no behaviour
+	// change until compression is explicitly turned on.
+	NoCompression CompressionType = iota
+	// Snappy is a low CPU overhead codec, it is the recommended choice for
+	// proposal payloads on the hot path.
+	Snappy
+	// Zstd gives a much better compression ratio at the cost of more CPU, it
+	// is the recommended choice for snapshot chunks carrying cold RSM state.
+	Zstd
+)
+
+const (
+	// CompressionCodecByteSize is the number of bytes reserved in a snapshot
+	// chunk header to record the CompressionType used for that chunk.
+	CompressionCodecByteSize uint64 = 1
+	// UncompressedLengthSize is the number of bytes reserved in a snapshot
+	// chunk header to record the uncompressed length of the chunk payload,
+	// so a receiver knows up front how large a buffer to allocate for the
+	// decompressed result, and can detect a truncated or corrupt chunk.
+	UncompressedLengthSize uint64 = 8
+	// MinCompressionSize is the minimum size in bytes a proposal payload must
+	// reach before compression is attempted. Payloads smaller than this are
+	// sent uncompressed as the codec overhead outweighs any gain.
+	MinCompressionSize uint64 = 4 * 1024
+)
@@ -84,6 +84,15 @@ type hard struct {
 	LRUMaxSessionCount uint64
 	// LogDBEntryBatchSize is the max size of each entry batch.
 	LogDBEntryBatchSize uint64
+	// MaxUncommittedEntriesSize is the max total size in bytes of uncommitted
+	// entries a leader is allowed to hold in its in-memory log before it starts
+	// rejecting new proposals. This protects against unbounded memory growth
+	// when a leader can no longer make progress, e.g. when it is partitioned
+	// away from the quorum. An entry counts towards this limit from the moment
+	// it is appended until it has been applied, so entries already committed
+	// but not yet applied still count. The empty entries a leader appends on
+	// election are always accepted regardless of this limit.
+	MaxUncommittedEntriesSize uint64
 }
 
 const (
@@ -93,6 +102,14 @@ const (
 
 	// SnapshotHeaderSize defines the snapshot header size in number of bytes.
 	SnapshotHeaderSize uint64 = 1024
+	// MaxCommittedSizePerReady is the max total size in bytes of the
+	// CommittedEntries included in a single Ready struct handed to the step
+	// engine. It bounds the size of each apply batch independently from
+	// MaxMessageSize so the apply loop latency and RSM worker memory usage can
+	// be tuned without affecting replication batching. An entry bigger than
+	// this limit on its own is still returned, just in a batch of one, so
+	// progress never stalls.
+	MaxCommittedSizePerReady uint64 = MaxMessageSize
 
 	//
 	// transport
@@ -108,6 +125,26 @@ const (
 	// module.
 	SnapshotChunkSize uint64 = 2 * 1024 * 1024
 
+	//
+	// event stream
+	//
+
+	// EventStreamHeaderSize defines the size in number of bytes of the
+	// header frame sent once at the start of an event stream: the 4 byte
+	// length prefix, the frame type byte and the 4 byte schema version that
+	// describes the record frames that follow.
+	EventStreamHeaderSize uint64 = 9
+	// EventRecordHeaderSize defines the fixed size in number of bytes of each
+	// event stream record frame apart from its payload: the 4 byte length
+	// prefix, the frame type byte, the clusterID, the raft index, the term
+	// and the 4 byte CRC trailer computed over those three fields and the
+	// payload.
+	EventRecordHeaderSize uint64 = 33
+	// EventStreamHeartbeatTick defines how often, in number of ticks, a
+	// heartbeat/continuation frame is sent on an otherwise idle event stream
+	// so subscribers can tell the stream apart from a dead connection.
+	EventStreamHeartbeatTick uint64 = 10
+
 	//
 	// Drummer DB
 	//
@@ -118,12 +155,13 @@ const (
 )
 
 func (h *hard) Hash() uint64 {
-	hashstr := fmt.Sprintf("%d-%d-%t-%d-%d",
+	hashstr := fmt.Sprintf("%d-%d-%t-%d-%d-%d",
 		h.StepEngineWorkerCount,
 		h.LogDBPoolSize,
 		h.UseRocksDBRangeDelete,
 		h.LRUMaxSessionCount,
-		h.LogDBEntryBatchSize)
+		h.LogDBEntryBatchSize,
+		h.MaxUncommittedEntriesSize)
 	mh := md5.New()
 	if _, err := io.WriteString(mh, hashstr); err != nil {
 		panic(err)
@@ -145,5 +183,9 @@ func getDefaultHardSettings() hard {
 		UseRocksDBRangeDelete: false,
 		LRUMaxSessionCount:    4096,
 		LogDBEntryBatchSize:   48,
+		// default to twice the max proposal payload size allowed in flight,
+		// leaving enough headroom for a burst of proposals while still
+		// bounding the leader's in-memory log when it can not commit.
+		MaxUncommittedEntriesSize: 2 * MaxProposalPayloadSize,
 	}
 }
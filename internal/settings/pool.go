@@ -0,0 +1,64 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package settings
+
+// Priority is used to classify the work submitted to the step engine worker
+// pools so latency sensitive requests, e.g. linearizable reads, do not get
+// stuck behind a burst of background work on the same shared queue.
+type Priority uint64
+
+const (
+	// Low is the priority used for background work such as snapshotting and
+	// log compaction.
+	Low Priority = iota
+	// Normal is the priority used for regular proposals.
+	Normal
+	// High is the priority used for latency sensitive work such as
+	// ReadIndex based linearizable reads.
+	High
+)
+
+// PoolSettings is the tiered worker pool settings used to run the step
+// engine. Unlike the fields in hard, changing these values does not corrupt
+// already persisted data, they only affect how much concurrency is given to
+// each priority tier and how much work is allowed to queue up per worker
+// before new requests are rejected with ErrSystemBusy.
+type PoolSettings struct {
+	// WorkerCount is the number of workers dedicated to each priority tier.
+	WorkerCount map[Priority]uint64
+	// MaxInFlightTasksPerWorker is the max number of queued tasks each worker
+	// in a tier is allowed to hold before requests of that priority start
+	// being rejected as busy rather than queued indefinitely.
+	MaxInFlightTasksPerWorker map[Priority]uint64
+}
+
+// DefaultPoolSettings returns the default tiered worker pool settings. The
+// three tiers together add up to the previous single StepEngineWorkerCount
+// default so out of the box throughput is unchanged, it is just no longer
+// possible for a burst of Low priority work to starve High priority reads.
+func DefaultPoolSettings() PoolSettings {
+	return PoolSettings{
+		WorkerCount: map[Priority]uint64{
+			Low:    2,
+			Normal: 10,
+			High:   4,
+		},
+		MaxInFlightTasksPerWorker: map[Priority]uint64{
+			Low:    64,
+			Normal: 256,
+			High:   256,
+		},
+	}
+}
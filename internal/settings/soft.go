@@ -0,0 +1,241 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/lni/dragonboat/logger"
+)
+
+//
+// This file contains the soft configuration values, they are safe to change
+// while the system is running as none of them affect how data is persisted
+// on disk. Unlike the Hard struct above which is only read once on process
+// start, Soft is reloaded whenever the process receives a SIGHUP, the new
+// values are obtained by merging, in order, a base config file, an optional
+// per environment overlay and an optional local override file, the last
+// file encountered wins for any given field.
+//
+
+// Soft is the soft settings that can be tuned at runtime without restarting
+// the process or risking data corruption. Fields that would corrupt data if
+// changed after start, e.g. hard.StepEngineWorkerCount which determines how
+// nodes are partitioned across LogDB instances, are NOT duplicated here -
+// they stay exclusively in hard and are rejected by WatchSoftSettings if a
+// config file tries to set them.
+type Soft struct {
+	// RaftTickDurationMillisecond is the interval, in millisecond, between
+	// two consecutive raft ticks.
+	RaftTickDurationMillisecond uint64
+	// LogLevel is the name of the log level used by the logger package, it
+	// must be one of the names in logLevelByName.
+	LogLevel string
+	// SnapshotThrottleMillisecond is the minimum delay enforced between two
+	// snapshot chunks being sent to the same target, used to avoid a large
+	// snapshot transfer saturating the link used for normal replication.
+	SnapshotThrottleMillisecond uint64
+}
+
+const (
+	minRaftTickDurationMillisecond uint64 = 10
+	maxRaftTickDurationMillisecond uint64 = 5000
+	maxSnapshotThrottleMillisecond uint64 = 60000
+	// maxUint64AsFloat is used to bound-check JSON numbers (which always
+	// decode as float64) before converting them to a uint64 field. Note
+	// that float64 can't represent math.MaxUint64 (1<<64 - 1) exactly, the
+	// constant below rounds up to exactly 1<<64, so the comparison in
+	// setSoftField must reject values equal to it too, not just values
+	// greater than it, or a JSON value of exactly 1<<64 would be let
+	// through and silently wrap to 0 on conversion.
+	maxUint64AsFloat float64 = 1 << 64
+)
+
+// logLevelByName maps the accepted Soft.LogLevel names to the logger
+// package's LogLevel values.
+var logLevelByName = map[string]logger.LogLevel{
+	"CRITICAL": logger.CRITICAL,
+	"ERROR":    logger.ERROR,
+	"WARNING":  logger.WARNING,
+	"INFO":     logger.INFO,
+	"DEBUG":    logger.DEBUG,
+}
+
+// hardOnlyFieldNames lists the hard struct field names that must never be
+// set through a soft settings config file, attempting to do so is rejected
+// rather than silently ignored.
+var hardOnlyFieldNames = map[string]struct{}{
+	"StepEngineWorkerCount":     {},
+	"LogDBPoolSize":             {},
+	"UseRocksDBRangeDelete":     {},
+	"LRUMaxSessionCount":        {},
+	"LogDBEntryBatchSize":       {},
+	"MaxUncommittedEntriesSize": {},
+}
+
+var (
+	// soft is the current soft settings, it is guarded by softMu as it can be
+	// replaced concurrently by the SIGHUP handler.
+	soft   = getDefaultSoftSettings()
+	softMu sync.RWMutex
+)
+
+// GetSoft returns a copy of the current soft settings.
+func GetSoft() Soft {
+	softMu.RLock()
+	defer softMu.RUnlock()
+	return soft
+}
+
+func getDefaultSoftSettings() Soft {
+	return Soft{
+		RaftTickDurationMillisecond: 100,
+		LogLevel:                    "INFO",
+		SnapshotThrottleMillisecond: 0,
+	}
+}
+
+// WatchSoftSettings loads the soft settings by merging the given config
+// files in order and applies the result immediately, it then installs a
+// SIGHUP handler that repeats the same merge and apply whenever the signal
+// is received. If any file can not be merged or any field fails validation,
+// the whole merged result is rejected and every field keeps its previously
+// applied value; the error is returned for the initial load and logged for
+// a SIGHUP triggered reload.
+func WatchSoftSettings(files ...string) error {
+	if err := reloadSoftSettings(files); err != nil {
+		return err
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := reloadSoftSettings(files); err != nil {
+				plog.Errorf("failed to reload soft settings, %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func reloadSoftSettings(files []string) error {
+	merged := getDefaultSoftSettings()
+	for _, fp := range files {
+		if err := mergeSoftSettingsFile(fp, &merged); err != nil {
+			return err
+		}
+	}
+	if err := validateSoftSettings(&merged); err != nil {
+		return err
+	}
+	softMu.Lock()
+	soft = merged
+	softMu.Unlock()
+	plog.SetLevel(logLevelByName[merged.LogLevel])
+	plog.Infof("soft settings reloaded from %v", files)
+	return nil
+}
+
+func mergeSoftSettingsFile(fp string, s *Soft) error {
+	data, err := ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%s is not a valid soft settings file, %v", fp, err)
+	}
+	return applyRawSoftSettings(raw, s)
+}
+
+// applyRawSoftSettings copies the fields present in raw onto s, using
+// reflection so any field added to the Soft struct in the future is picked
+// up without further changes here. Fields present in raw but unknown to the
+// Soft struct, fields that belong to hard instead, or whose JSON value does
+// not cleanly fit the struct field's type, are all rejected rather than
+// silently ignored, truncated or wrapped.
+func applyRawSoftSettings(raw map[string]interface{}, s *Soft) error {
+	v := reflect.ValueOf(s).Elem()
+	t := v.Type()
+	for name, val := range raw {
+		if _, ok := hardOnlyFieldNames[name]; ok {
+			return fmt.Errorf("%q is a hard setting and can not be changed at runtime", name)
+		}
+		field := v.FieldByName(name)
+		if !field.IsValid() {
+			return fmt.Errorf("unknown soft setting %q", name)
+		}
+		sf, _ := t.FieldByName(name)
+		if err := setSoftField(field, sf.Type, name, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSoftField sets field, whose static type is fieldType, to the decoded
+// JSON value val. JSON numbers always decode to float64, so uint64 fields
+// are only accepted when val is a non-negative integral value that fits
+// without loss, anything else (negative, fractional, out of range) is
+// rejected instead of being silently truncated or wrapped by a blind
+// reflect.Value.Convert.
+func setSoftField(field reflect.Value, fieldType reflect.Type, name string, val interface{}) error {
+	switch fieldType.Kind() {
+	case reflect.Uint64:
+		f, ok := val.(float64)
+		if !ok {
+			return fmt.Errorf("soft setting %q must be a number", name)
+		}
+		if f < 0 || f != math.Trunc(f) || f >= maxUint64AsFloat {
+			return fmt.Errorf("soft setting %q must be a non-negative integer that fits in a uint64, got %v", name, val)
+		}
+		field.SetUint(uint64(f))
+	case reflect.String:
+		sv, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("soft setting %q must be a string", name)
+		}
+		field.SetString(sv)
+	default:
+		return fmt.Errorf("soft setting %q has an unsupported type %v", name, fieldType)
+	}
+	return nil
+}
+
+func validateSoftSettings(s *Soft) error {
+	if s.RaftTickDurationMillisecond < minRaftTickDurationMillisecond ||
+		s.RaftTickDurationMillisecond > maxRaftTickDurationMillisecond {
+		return fmt.Errorf("RaftTickDurationMillisecond %d out of range [%d, %d]",
+			s.RaftTickDurationMillisecond, minRaftTickDurationMillisecond, maxRaftTickDurationMillisecond)
+	}
+	if _, ok := logLevelByName[s.LogLevel]; !ok {
+		return fmt.Errorf("LogLevel %q is not a recognized log level", s.LogLevel)
+	}
+	if s.SnapshotThrottleMillisecond > maxSnapshotThrottleMillisecond {
+		return fmt.Errorf("SnapshotThrottleMillisecond %d exceeds the max of %d",
+			s.SnapshotThrottleMillisecond, maxSnapshotThrottleMillisecond)
+	}
+	return nil
+}
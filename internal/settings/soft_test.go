@@ -0,0 +1,99 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package settings
+
+import "testing"
+
+func TestApplyRawSoftSettingsRejectsFractionalUint64(t *testing.T) {
+	s := getDefaultSoftSettings()
+	raw := map[string]interface{}{"RaftTickDurationMillisecond": 100.5}
+	if err := applyRawSoftSettings(raw, &s); err == nil {
+		t.Fatal("expected a fractional value to be rejected")
+	}
+}
+
+func TestApplyRawSoftSettingsRejectsNegativeUint64(t *testing.T) {
+	s := getDefaultSoftSettings()
+	raw := map[string]interface{}{"RaftTickDurationMillisecond": -5.0}
+	if err := applyRawSoftSettings(raw, &s); err == nil {
+		t.Fatal("expected a negative value to be rejected")
+	}
+}
+
+func TestApplyRawSoftSettingsRejectsOverflowingUint64(t *testing.T) {
+	s := getDefaultSoftSettings()
+	raw := map[string]interface{}{"RaftTickDurationMillisecond": 1e20}
+	if err := applyRawSoftSettings(raw, &s); err == nil {
+		t.Fatal("expected a value overflowing uint64 to be rejected")
+	}
+}
+
+func TestApplyRawSoftSettingsRejectsExactlyTwoToThe64(t *testing.T) {
+	// float64 can't represent math.MaxUint64 (1<<64 - 1) exactly, it rounds
+	// up to 1<<64, so this is the smallest float64 value that must be
+	// rejected - a >, rather than >=, bound check would let it through and
+	// silently wrap to 0 on conversion to uint64.
+	s := getDefaultSoftSettings()
+	raw := map[string]interface{}{"RaftTickDurationMillisecond": float64(1 << 64)}
+	if err := applyRawSoftSettings(raw, &s); err == nil {
+		t.Fatal("expected 1<<64 to be rejected as overflowing uint64")
+	}
+}
+
+func TestApplyRawSoftSettingsRejectsHardOnlyFields(t *testing.T) {
+	s := getDefaultSoftSettings()
+	raw := map[string]interface{}{"StepEngineWorkerCount": 32.0}
+	if err := applyRawSoftSettings(raw, &s); err == nil {
+		t.Fatal("expected a hard-only field to be rejected")
+	}
+}
+
+func TestApplyRawSoftSettingsAcceptsValidValues(t *testing.T) {
+	s := getDefaultSoftSettings()
+	raw := map[string]interface{}{
+		"RaftTickDurationMillisecond": 200.0,
+		"LogLevel":                    "DEBUG",
+	}
+	if err := applyRawSoftSettings(raw, &s); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if s.RaftTickDurationMillisecond != 200 || s.LogLevel != "DEBUG" {
+		t.Fatalf("unexpected result, %+v", s)
+	}
+}
+
+func TestValidateSoftSettingsRejectsBadLogLevel(t *testing.T) {
+	s := getDefaultSoftSettings()
+	s.LogLevel = "BOGUS"
+	if err := validateSoftSettings(&s); err == nil {
+		t.Fatal("expected an unrecognized log level to be rejected")
+	}
+}
+
+func TestValidateSoftSettingsRejectsOutOfRangeTick(t *testing.T) {
+	s := getDefaultSoftSettings()
+	s.RaftTickDurationMillisecond = 0
+	if err := validateSoftSettings(&s); err == nil {
+		t.Fatal("expected a zero tick duration to be rejected")
+	}
+}
+
+func TestValidateSoftSettingsRejectsExcessiveThrottle(t *testing.T) {
+	s := getDefaultSoftSettings()
+	s.SnapshotThrottleMillisecond = maxSnapshotThrottleMillisecond + 1
+	if err := validateSoftSettings(&s); err == nil {
+		t.Fatal("expected an excessive throttle value to be rejected")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transport implements the gRPC based transport module used to
+// exchange raft messages, snapshot chunks and proposals between nodes.
+package transport
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/lni/dragonboat/internal/settings"
+)
+
+// codec compresses and decompresses chunk/proposal payloads for a single
+// settings.CompressionType.
+type codec interface {
+	compress(data []byte) ([]byte, error)
+	decompress(data []byte) ([]byte, error)
+}
+
+type noopCodec struct{}
+
+func (noopCodec) compress(data []byte) ([]byte, error)   { return data, nil }
+func (noopCodec) decompress(data []byte) ([]byte, error) { return data, nil }
+
+// flateCodec is used to back both the Snappy and Zstd settings.
+// CompressionType selectors until the actual snappy/zstd bindings are
+// vendored, Snappy picks flate.BestSpeed to stay cheap on the hot
+// proposal path while Zstd picks flate.BestCompression for the better
+// ratio wanted on cold snapshot data.
+type flateCodec struct {
+	level int
+}
+
+func (c flateCodec) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCodec) decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func getCodec(t settings.CompressionType) (codec, error) {
+	switch t {
+	case settings.NoCompression:
+		return noopCodec{}, nil
+	case settings.Snappy:
+		return flateCodec{level: flate.BestSpeed}, nil
+	case settings.Zstd:
+		return flateCodec{level: flate.BestCompression}, nil
+	default:
+		return nil, fmt.Errorf("unknown compression type %d", t)
+	}
+}
+
+// headerSize is the total size, in bytes, of the fixed chunk prelude this
+// package adds in front of every (possibly compressed) payload: one codec
+// byte followed by the uncompressed length.
+const headerSize = int(settings.CompressionCodecByteSize + settings.UncompressedLengthSize)
+
+// encodeChunk compresses data with the codec for t and prefixes it with a
+// header recording the codec used and the uncompressed length, so a
+// receiver knows up front how large a buffer to allocate for the
+// decompressed result. If compressing data with t does not actually make
+// it any smaller, e.g. already-compressed RSM state, the chunk is stored
+// uncompressed instead so no CPU is spent for nothing.
+func encodeChunk(t settings.CompressionType, data []byte) ([]byte, error) {
+	if uint64(len(data)) < settings.MinCompressionSize {
+		t = settings.NoCompression
+	}
+	c, err := getCodec(t)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := c.compress(data)
+	if err != nil {
+		return nil, err
+	}
+	if t != settings.NoCompression && len(compressed) >= len(data) {
+		// negative gain, fall back to storing the chunk uncompressed rather
+		// than paying the CPU cost for no benefit.
+		t = settings.NoCompression
+		compressed = data
+	}
+	header := make([]byte, headerSize)
+	header[0] = byte(t)
+	binary.LittleEndian.PutUint64(header[settings.CompressionCodecByteSize:], uint64(len(data)))
+	return append(header, compressed...), nil
+}
+
+// prepareSnapshotChunk encodes a snapshot chunk and, for every chunk after
+// the first in a transfer, applies the configured inter-chunk throttle so
+// sending it does not saturate the link also used for normal replication
+// traffic. chunkIndex is the chunk's position within its snapshot transfer,
+// starting at 0, the first chunk is never throttled since there is no
+// preceding chunk to space it out from.
+func prepareSnapshotChunk(t settings.CompressionType, data []byte, chunkIndex uint64) ([]byte, error) {
+	encoded, err := encodeChunk(t, data)
+	if err != nil {
+		return nil, err
+	}
+	if chunkIndex > 0 {
+		throttleSnapshotChunk()
+	}
+	return encoded, nil
+}
+
+// decodeChunk reverses encodeChunk, returning the original uncompressed
+// payload. The chunk is decompressed in full into memory, it is not a
+// streaming decompressor.
+func decodeChunk(data []byte) ([]byte, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("chunk too small to contain a header, got %d bytes", len(data))
+	}
+	t := settings.CompressionType(data[0])
+	uncompressedLen := binary.LittleEndian.Uint64(data[settings.CompressionCodecByteSize:headerSize])
+	c, err := getCodec(t)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.decompress(data[headerSize:])
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(out)) != uncompressedLen {
+		return nil, fmt.Errorf("decompressed length %d does not match header length %d",
+			len(out), uncompressedLen)
+	}
+	return out, nil
+}
+
+// PrepareSnapshotChunk is the exported entry point used by NodeHost to
+// encode and, for every chunk after the first in a transfer, throttle a
+// snapshot chunk before it is put on the wire. See prepareSnapshotChunk.
+func PrepareSnapshotChunk(t settings.CompressionType, data []byte, chunkIndex uint64) ([]byte, error) {
+	return prepareSnapshotChunk(t, data, chunkIndex)
+}
+
+// DecodeChunk is the exported entry point used by NodeHost to reverse
+// PrepareSnapshotChunk on the receiving end. See decodeChunk.
+func DecodeChunk(data []byte) ([]byte, error) {
+	return decodeChunk(data)
+}
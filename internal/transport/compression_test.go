@@ -0,0 +1,104 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/lni/dragonboat/internal/settings"
+)
+
+func TestEncodeDecodeChunkRoundTrips(t *testing.T) {
+	data := bytes.Repeat([]byte("dragonboat-raft-log-entry"), 1024)
+	for _, ct := range []settings.CompressionType{
+		settings.NoCompression, settings.Snappy, settings.Zstd,
+	} {
+		encoded, err := encodeChunk(ct, data)
+		if err != nil {
+			t.Fatalf("encodeChunk failed, %v", err)
+		}
+		decoded, err := decodeChunk(encoded)
+		if err != nil {
+			t.Fatalf("decodeChunk failed, %v", err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("round trip mismatch for compression type %d", ct)
+		}
+	}
+}
+
+func TestEncodeChunkFallsBackWhenCompressionHasNegativeGain(t *testing.T) {
+	// random bytes above MinCompressionSize do not compress well, flate
+	// output on them ends up larger than the input, exercising the
+	// negative-gain fast path.
+	data := make([]byte, settings.MinCompressionSize+1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("failed to generate random data, %v", err)
+	}
+	encoded, err := encodeChunk(settings.Zstd, data)
+	if err != nil {
+		t.Fatalf("encodeChunk failed, %v", err)
+	}
+	if settings.CompressionType(encoded[0]) != settings.NoCompression {
+		t.Fatalf("expected the fast path to fall back to NoCompression, got codec %d", encoded[0])
+	}
+	decoded, err := decodeChunk(encoded)
+	if err != nil {
+		t.Fatalf("decodeChunk failed, %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("round trip mismatch after falling back to no compression")
+	}
+}
+
+func TestPrepareSnapshotChunkEncodesLaterChunk(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), int(settings.MinCompressionSize)+1)
+	encoded, err := prepareSnapshotChunk(settings.NoCompression, data, 1)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	decoded, err := decodeChunk(encoded)
+	if err != nil {
+		t.Fatalf("decodeChunk failed, %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("round trip mismatch")
+	}
+}
+
+func TestPrepareSnapshotChunkDoesNotThrottleFirstChunk(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), int(settings.MinCompressionSize)+1)
+	start := time.Now()
+	if _, err := prepareSnapshotChunk(settings.NoCompression, data, 0); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("the first chunk of a transfer should never be throttled, took %v", elapsed)
+	}
+}
+
+func TestEncodeChunkSkipsSmallPayloads(t *testing.T) {
+	data := []byte("tiny")
+	encoded, err := encodeChunk(settings.Snappy, data)
+	if err != nil {
+		t.Fatalf("encodeChunk failed, %v", err)
+	}
+	if settings.CompressionType(encoded[0]) != settings.NoCompression {
+		t.Fatalf("expected small payloads to skip compression, got codec %d", encoded[0])
+	}
+}
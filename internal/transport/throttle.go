@@ -0,0 +1,32 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"time"
+
+	"github.com/lni/dragonboat/internal/settings"
+)
+
+// throttleSnapshotChunk blocks for settings.GetSoft().SnapshotThrottleMillisecond
+// before returning, it is called between two consecutive chunks sent to the
+// same target so a large snapshot transfer does not saturate the link also
+// used for normal replication traffic. It reads the soft setting on every
+// call so a SIGHUP driven change takes effect on the very next chunk.
+func throttleSnapshotChunk() {
+	if ms := settings.GetSoft().SnapshotThrottleMillisecond; ms > 0 {
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+	}
+}
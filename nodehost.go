@@ -0,0 +1,238 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dragonboat is a multi-group Raft library.
+package dragonboat
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lni/dragonboat/internal/eventstream"
+	"github.com/lni/dragonboat/internal/raft"
+	"github.com/lni/dragonboat/internal/settings"
+	"github.com/lni/dragonboat/internal/transport"
+)
+
+// ErrClusterNotFound is returned when the requested clusterID is not
+// managed by this NodeHost.
+var ErrClusterNotFound = errors.New("cluster not found")
+
+// NodeHost is the top level access point applications use to interact with
+// the raft clusters running on the local node.
+type NodeHost struct {
+	nhConfig NodeHostConfig
+	clusters map[uint64]*cluster
+}
+
+// cluster binds a cluster's raft node to its step engine, its tail of newly
+// committed entries is also used to feed eventstream.Subscribe once a
+// subscriber's backlog has been drained.
+type cluster struct {
+	clusterID uint64
+	// config is this cluster's Config as passed to StartCluster, it is kept
+	// around so PrepareSnapshotChunk can resolve the cluster's effective
+	// CompressionType.
+	config Config
+	// node is this cluster's raft instance. Proposals made through
+	// NodeHost.syncPropose are appended to it, so
+	// settings.Hard.MaxUncommittedEntriesSize is actually enforced instead
+	// of being a limit nothing ever checks.
+	node *raft.Node
+	// tailCh carries newly committed entries to any active Subscribe call
+	// for this cluster, tickCh drives its idle heartbeat.
+	tailCh chan eventstream.RecordFrame
+	tickCh chan struct{}
+	// nextIndex is the index to assign to the next proposed entry. Real
+	// multi-node replication will derive this from the raft log instead,
+	// it is tracked here only because this snapshot does not yet implement
+	// that part of raft.
+	nextIndex uint64
+	// stopCh is closed by NodeHost.Stop to shut down this cluster's tick
+	// goroutine.
+	stopCh chan struct{}
+}
+
+// StartCluster creates the cluster described by cfg and registers it with
+// nh so it can be used with SyncProposeWithPriority, ReadIndexWithPriority,
+// Subscribe and PrepareSnapshotChunk. This snapshot does not yet implement leader
+// election or replication, so every started cluster is its own leader.
+func (nh *NodeHost) StartCluster(cfg Config) {
+	nh.newCluster(cfg)
+}
+
+func (nh *NodeHost) newCluster(cfg Config) *cluster {
+	node := raft.NewNode(cfg.ClusterID, cfg.NodeID)
+	node.BecomeLeader(1)
+	c := &cluster{
+		clusterID: cfg.ClusterID,
+		config:    cfg,
+		node:      node,
+		tailCh:    make(chan eventstream.RecordFrame),
+		tickCh:    make(chan struct{}),
+		stopCh:    make(chan struct{}),
+	}
+	nh.clusters[cfg.ClusterID] = c
+	go c.runTicker()
+	return c
+}
+
+// runTicker drives c.tickCh off a raft.Ticker at the current soft tick
+// interval, this is what lets eventstream.Subscribe's heartbeat actually
+// fire on an idle cluster instead of ticks never arriving.
+func (c *cluster) runTicker() {
+	ticker := raft.NewTicker()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C():
+			select {
+			case c.tickCh <- struct{}{}:
+			default:
+			}
+			ticker.Reset()
+		}
+	}
+}
+
+func (c *cluster) tailChannel() <-chan eventstream.RecordFrame {
+	return c.tailCh
+}
+
+func (c *cluster) tickChannel() <-chan struct{} {
+	return c.tickCh
+}
+
+// clusterLogReader is the eventstream.LogReader used for a cluster. It holds
+// no log access: EntriesFrom always returns no entries and no error, so a
+// subscriber is never sent a backlog.
+type clusterLogReader struct {
+	clusterID uint64
+}
+
+func (r clusterLogReader) EntriesFrom(clusterID uint64,
+	fromIndex uint64) ([]eventstream.RecordFrame, error) {
+	return nil, nil
+}
+
+// clusterSnapshotProvider is the eventstream.SnapshotProvider used for a
+// cluster. It holds no snapshot access: LatestSnapshot always returns index
+// 0 and no error.
+type clusterSnapshotProvider struct {
+	clusterID uint64
+}
+
+func (p clusterSnapshotProvider) LatestSnapshot(clusterID uint64) (uint64, error) {
+	return 0, nil
+}
+
+func (c *cluster) logReader() eventstream.LogReader {
+	return clusterLogReader{clusterID: c.clusterID}
+}
+
+func (c *cluster) snapshotProvider() eventstream.SnapshotProvider {
+	return clusterSnapshotProvider{clusterID: c.clusterID}
+}
+
+// NewNodeHost creates a new NodeHost instance configured as described by
+// nhc. When nhc.SoftSettingsFiles is non-empty, it is loaded immediately
+// via settings.WatchSoftSettings, which also installs the SIGHUP reload
+// handler for the life of the process.
+func NewNodeHost(nhc NodeHostConfig) (*NodeHost, error) {
+	if len(nhc.SoftSettingsFiles) > 0 {
+		if err := settings.WatchSoftSettings(nhc.SoftSettingsFiles...); err != nil {
+			return nil, err
+		}
+	}
+	return &NodeHost{nhConfig: nhc, clusters: make(map[uint64]*cluster)}, nil
+}
+
+// Stop shuts down nh, stopping every started cluster's background tick
+// goroutine.
+func (nh *NodeHost) Stop() {
+	for _, c := range nh.clusters {
+		close(c.stopCh)
+	}
+}
+
+// syncPropose is the actual proposal path used by SyncProposeWithPriority,
+// it appends cmd to the named cluster's raft log,
+// applies it and notifies any active Subscribe call of the newly committed
+// entry. raft.ErrUncommittedSizeLimitReached is returned unchanged when
+// accepting cmd would push the cluster's leader past
+// settings.Hard.MaxUncommittedEntriesSize.
+func (nh *NodeHost) syncPropose(ctx context.Context,
+	clusterID uint64, cmd []byte) (Result, error) {
+	c, ok := nh.clusters[clusterID]
+	if !ok {
+		return Result{}, ErrClusterNotFound
+	}
+	c.nextIndex++
+	entry := raft.Entry{Index: c.nextIndex, Term: 1, Data: cmd}
+	if err := c.node.Propose([]raft.Entry{entry}); err != nil {
+		return Result{}, err
+	}
+	// this snapshot does not yet implement replication, so a proposal is
+	// committed as soon as it is appended rather than after a quorum acks
+	// it.
+	c.node.CommitTo(entry.Index)
+	for _, applied := range c.node.NextCommittedEntries() {
+		c.node.Applied([]raft.Entry{applied})
+		select {
+		case c.tailCh <- eventstream.RecordFrame{
+			ClusterID: clusterID,
+			Index:     applied.Index,
+			Term:      applied.Term,
+			Payload:   applied.Data,
+		}:
+		default:
+			// no subscriber is waiting to receive, drop rather than block
+			// the proposer.
+		}
+	}
+	return Result{}, nil
+}
+
+// PrepareSnapshotChunk encodes data as chunkIndex's chunk of a snapshot
+// transfer for clusterID, compressing it with the cluster's effective
+// CompressionType (Config.CompressionType if the cluster set one,
+// otherwise the NodeHostConfig default) and throttling every chunk after
+// the first in the transfer.
+func (nh *NodeHost) PrepareSnapshotChunk(clusterID uint64,
+	data []byte, chunkIndex uint64) ([]byte, error) {
+	c, ok := nh.clusters[clusterID]
+	if !ok {
+		return nil, ErrClusterNotFound
+	}
+	ct := c.config.GetCompressionType(nh.nhConfig)
+	return transport.PrepareSnapshotChunk(ct, data, chunkIndex)
+}
+
+// DecodeSnapshotChunk reverses PrepareSnapshotChunk, returning the
+// original uncompressed chunk payload.
+func (nh *NodeHost) DecodeSnapshotChunk(data []byte) ([]byte, error) {
+	return transport.DecodeChunk(data)
+}
+
+// readIndex is the actual read path used by ReadIndexWithPriority. It only
+// checks that clusterID is managed by nh, it does not confirm that the local
+// node is still the leader of clusterID.
+func (nh *NodeHost) readIndex(ctx context.Context, clusterID uint64) (Result, error) {
+	if _, ok := nh.clusters[clusterID]; !ok {
+		return Result{}, ErrClusterNotFound
+	}
+	return Result{}, nil
+}
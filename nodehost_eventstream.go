@@ -0,0 +1,44 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dragonboat
+
+import (
+	"context"
+
+	"github.com/lni/dragonboat/internal/eventstream"
+)
+
+// Subscribe streams committed entries for clusterID to sink as a
+// length-prefixed binary frame stream, so external consumers such as CDC
+// pipelines or secondary indices can tail RSM state changes without polling
+// the cluster with reads. The cluster's log reader holds no entries, so no
+// backlog from fromIndex is sent: after the header frame, Subscribe keeps
+// running, forwarding entries committed while it waits to receive them and
+// sending heartbeat frames on an otherwise idle stream, until ctx is
+// cancelled.
+func (nh *NodeHost) Subscribe(ctx context.Context, clusterID uint64,
+	fromIndex uint64, sink eventstream.Sink) error {
+	c, ok := nh.clusters[clusterID]
+	if !ok {
+		return ErrClusterNotFound
+	}
+	stop := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(stop)
+	}()
+	return eventstream.Subscribe(clusterID, fromIndex, c.logReader(),
+		c.snapshotProvider(), c.tailChannel(), c.tickChannel(), stop, sink)
+}
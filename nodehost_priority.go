@@ -0,0 +1,89 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dragonboat
+
+import (
+	"context"
+
+	"github.com/lni/dragonboat/internal/server"
+	"github.com/lni/dragonboat/internal/settings"
+)
+
+// Result is the outcome of a proposal or a linearizable read.
+type Result struct {
+	Value uint64
+	Data  []byte
+}
+
+// pools is the process wide set of tiered worker pools used to admit
+// proposals and reads at their respective priorities. It is created when
+// the package is initialised, so its worker goroutines run whether or not
+// the *WithPriority methods are ever called.
+var pools = server.NewPools(settings.DefaultPoolSettings())
+
+// SyncProposeWithPriority proposes cmd to clusterID and waits for it to be
+// applied, letting the caller pick which priority tier admits the proposal.
+// Latency sensitive clients should use settings.High, background or
+// bulk-load style proposals should use settings.Low so they do not starve
+// everything else sharing the same shard. server.ErrSystemBusy is returned
+// immediately, without blocking, when the
+// chosen tier's queue is already full.
+func (nh *NodeHost) SyncProposeWithPriority(ctx context.Context,
+	clusterID uint64, priority settings.Priority, cmd []byte) (Result, error) {
+	type outcome struct {
+		result Result
+		err    error
+	}
+	ch := make(chan outcome, 1)
+	if err := pools.Submit(priority, func() {
+		// the actual append to the raft log and the wait for it to be
+		// applied happens here once the task is dequeued by a worker in
+		// the chosen tier.
+		res, err := nh.syncPropose(ctx, clusterID, cmd)
+		ch <- outcome{result: res, err: err}
+	}); err != nil {
+		return Result{}, err
+	}
+	select {
+	case o := <-ch:
+		return o.result, o.err
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// ReadIndexWithPriority runs a read request for clusterID, see readIndex,
+// letting the caller pick which priority tier admits it, see
+// SyncProposeWithPriority for how priorities are used.
+func (nh *NodeHost) ReadIndexWithPriority(ctx context.Context,
+	clusterID uint64, priority settings.Priority) (Result, error) {
+	type outcome struct {
+		result Result
+		err    error
+	}
+	ch := make(chan outcome, 1)
+	if err := pools.Submit(priority, func() {
+		res, err := nh.readIndex(ctx, clusterID)
+		ch <- outcome{result: res, err: err}
+	}); err != nil {
+		return Result{}, err
+	}
+	select {
+	case o := <-ch:
+		return o.result, o.err
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
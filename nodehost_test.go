@@ -0,0 +1,138 @@
+// Copyright 2017-2019 Lei Ni (nilei81@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dragonboat
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lni/dragonboat/internal/settings"
+)
+
+func newTestNodeHost(t *testing.T) *NodeHost {
+	t.Helper()
+	nh, err := NewNodeHost(NodeHostConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	return nh
+}
+
+func TestSyncProposeRejectsUnknownCluster(t *testing.T) {
+	nh := newTestNodeHost(t)
+	defer nh.Stop()
+	if _, err := nh.syncPropose(context.Background(), 1, []byte("v")); err != ErrClusterNotFound {
+		t.Fatalf("expected ErrClusterNotFound, got %v", err)
+	}
+}
+
+func TestSyncProposeAppliesEntryWithoutSubscriber(t *testing.T) {
+	nh := newTestNodeHost(t)
+	defer nh.Stop()
+	nh.StartCluster(Config{ClusterID: 1, NodeID: 1})
+	c := nh.clusters[1]
+	if _, err := nh.syncPropose(context.Background(), 1, []byte("v")); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	select {
+	case frame := <-c.tailCh:
+		t.Fatalf("did not expect a buffered frame without an active subscriber, got %+v", frame)
+	default:
+	}
+	if c.node.Status().UncommittedLogSize != 0 {
+		t.Fatalf("expected the proposed entry to have been committed and applied")
+	}
+}
+
+func TestNewNodeHostLoadsSoftSettingsFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodehost-soft-settings")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "soft.json")
+	if err := ioutil.WriteFile(fp, []byte(`{"RaftTickDurationMillisecond": 200}`), 0644); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	nh, err := NewNodeHost(NodeHostConfig{SoftSettingsFiles: []string{fp}})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	defer nh.Stop()
+	if got := settings.GetSoft().RaftTickDurationMillisecond; got != 200 {
+		t.Fatalf("expected the soft settings file to have been applied, got %d", got)
+	}
+}
+
+func TestNewNodeHostRejectsInvalidSoftSettingsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodehost-soft-settings")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "soft.json")
+	if err := ioutil.WriteFile(fp, []byte(`{"RaftTickDurationMillisecond": -1}`), 0644); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if _, err := NewNodeHost(NodeHostConfig{SoftSettingsFiles: []string{fp}}); err == nil {
+		t.Fatal("expected an invalid soft settings file to be rejected")
+	}
+}
+
+func TestPrepareAndDecodeSnapshotChunkUsesClusterCompressionOverride(t *testing.T) {
+	nh := newTestNodeHost(t)
+	defer nh.Stop()
+	zstd := settings.Zstd
+	nh.StartCluster(Config{ClusterID: 1, NodeID: 1, CompressionType: &zstd})
+	data := bytes.Repeat([]byte("dragonboat-snapshot-chunk"), 1024)
+	encoded, err := nh.PrepareSnapshotChunk(1, data, 0)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if settings.CompressionType(encoded[0]) != settings.Zstd {
+		t.Fatalf("expected the cluster's CompressionType override to be used, got codec %d", encoded[0])
+	}
+	decoded, err := nh.DecodeSnapshotChunk(encoded)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("round trip mismatch")
+	}
+}
+
+func TestPrepareSnapshotChunkRejectsUnknownCluster(t *testing.T) {
+	nh := newTestNodeHost(t)
+	defer nh.Stop()
+	if _, err := nh.PrepareSnapshotChunk(1, []byte("x"), 0); err != ErrClusterNotFound {
+		t.Fatalf("expected ErrClusterNotFound, got %v", err)
+	}
+}
+
+func TestSyncProposeEnforcesMaxUncommittedEntriesSize(t *testing.T) {
+	nh := newTestNodeHost(t)
+	defer nh.Stop()
+	nh.StartCluster(Config{ClusterID: 1, NodeID: 1})
+	// a single proposal bigger than the whole limit on its own must still
+	// be rejected rather than silently accepted and committed.
+	tooBig := make([]byte, settings.Hard.MaxUncommittedEntriesSize+1)
+	if _, err := nh.syncPropose(context.Background(), 1, tooBig); err == nil {
+		t.Fatalf("expected the uncommitted size limit to reject the proposal")
+	}
+}